@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"encoding/json"
+
+	"golang.org/x/net/context"
+	"gopkg.in/yaml.v3"
+)
+
+// Instance describes a single Resilio Sync container that btsyncw
+// should manage. A config file lists one or more of these.
+type Instance struct {
+	// A stable, unique name used to label the managed container and to
+	// match it up again on the next run.
+	Name string
+
+	// For Resilio directly
+	Folders []FolderSpec
+	Storage string
+	Ip      string
+	Network string
+
+	// For my docker image
+	Uid int64
+	Gid int64
+
+	// How the container should be restarted on exit, using Docker's
+	// `--restart` syntax: "no" (default), "always", "unless-stopped",
+	// "on-failure" or "on-failure:N".
+	RestartPolicy string
+}
+
+// The structure of the config file
+type Config struct {
+	// Which container engine to talk to. One of "docker" (default) or
+	// "podman".
+	Runtime RuntimeKind
+
+	// The Sync containers btsyncw should reconcile the host against.
+	Instances []Instance
+}
+
+// Construct an array of environment variables that the container needs
+func buildEnvVars(i *Instance) []string {
+	return []string{
+		"USERID=" + strconv.FormatInt(i.Uid, 10),
+		"GROUPID=" + strconv.FormatInt(i.Gid, 10),
+	}
+}
+
+// loadConfig reads and parses a config file, auto-detecting JSON vs
+// YAML from its extension (".yaml"/".yml" is YAML, anything else is
+// treated as JSON). Unknown fields are rejected so typos surface
+// immediately instead of being silently ignored.
+func loadConfig(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config: %w", err)
+	}
+	defer file.Close()
+
+	var c Config
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(file)
+		dec.KnownFields(true)
+		if err := dec.Decode(&c); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		}
+	default:
+		dec := json.NewDecoder(file)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&c); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+	}
+
+	return &c, nil
+}
+
+// validateInstance checks a single Instance for problems, returning
+// every problem it finds joined into one error rather than bailing out
+// on the first.
+func validateInstance(ctx context.Context, rt Runtime, i *Instance) error {
+	var errs []error
+
+	if i.Name == "" {
+		errs = append(errs, errors.New("'Name' field is required"))
+	}
+
+	if i.Storage == "" {
+		errs = append(errs, fmt.Errorf("'Storage' field is required for instance %q", i.Name))
+	}
+
+	for _, folder := range i.Folders {
+		if !filepath.IsAbs(folder.Source) {
+			errs = append(errs, fmt.Errorf("'Folders' entry %q must be an absolute path in instance %q", folder.Source, i.Name))
+			continue
+		}
+		info, err := os.Stat(folder.Source)
+		if err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("'Folders' entry %q must be an existing directory in instance %q", folder.Source, i.Name))
+		}
+
+		switch folder.SELinuxRelabel {
+		case SELinuxRelabelNone, SELinuxRelabelShared, SELinuxRelabelPrivate:
+		default:
+			errs = append(errs, fmt.Errorf("'SELinuxRelabel' of folder %q must be \"\", \"shared\" or \"private\" in instance %q", folder.Source, i.Name))
+		}
+
+		switch folder.Propagation {
+		case "", PropagationRPrivate, PropagationRShared, PropagationRSlave:
+		default:
+			errs = append(errs, fmt.Errorf("'Propagation' of folder %q must be \"rprivate\", \"rshared\" or \"rslave\" in instance %q", folder.Source, i.Name))
+		}
+	}
+
+	if i.Ip != "" && i.Network == "" {
+		errs = append(errs, fmt.Errorf("the field 'Ip' requires 'Network' in instance %q", i.Name))
+	}
+
+	if i.Ip != "" {
+		ip := net.ParseIP(i.Ip)
+		if ip == nil {
+			errs = append(errs, fmt.Errorf("'Ip' %q is not a valid IP address in instance %q", i.Ip, i.Name))
+		} else if i.Network != "" {
+			subnet, err := rt.InspectNetworkSubnet(ctx, i.Network)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to inspect network %q for instance %q: %w", i.Network, i.Name, err))
+			} else if !subnet.Contains(ip) {
+				errs = append(errs, fmt.Errorf("'Ip' %q is not within the subnet %s of network %q in instance %q", i.Ip, subnet, i.Network, i.Name))
+			}
+		}
+	}
+
+	if i.Uid == 0 || i.Gid == 0 {
+		errs = append(errs, fmt.Errorf("the fields 'Uid' and 'Gid' are required for instance %q", i.Name))
+	}
+
+	if _, err := ParseRestartPolicy(i.RestartPolicy); err != nil {
+		errs = append(errs, fmt.Errorf("invalid 'RestartPolicy' for instance %q: %w", i.Name, err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateRuntimeKind checks that k is a Runtime btsyncw actually
+// knows about. It doesn't need a constructed Runtime, so callers run
+// it before NewRuntime to fail with a proper validation error instead
+// of a raw connection error when the field itself is just wrong.
+func validateRuntimeKind(k RuntimeKind) error {
+	if k != "" && k != RuntimeDocker && k != RuntimePodman {
+		return fmt.Errorf("'Runtime' must be %q or %q, got %q", RuntimeDocker, RuntimePodman, k)
+	}
+	return nil
+}
+
+// validateConfig checks the whole Config for problems, returning every
+// problem it finds joined into one error so users see everything wrong
+// at once instead of fixing one field per run.
+func validateConfig(ctx context.Context, rt Runtime, c *Config) error {
+	var errs []error
+
+	if err := validateRuntimeKind(c.Runtime); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(c.Instances) == 0 {
+		errs = append(errs, errors.New("'Instances' must contain at least one entry"))
+	}
+
+	seenNames := make(map[string]bool, len(c.Instances))
+	for idx := range c.Instances {
+		i := &c.Instances[idx]
+		if err := validateInstance(ctx, rt, i); err != nil {
+			errs = append(errs, err)
+		}
+		if seenNames[i.Name] {
+			errs = append(errs, fmt.Errorf("duplicate instance name %q", i.Name))
+		}
+		seenNames[i.Name] = true
+	}
+
+	return errors.Join(errs...)
+}
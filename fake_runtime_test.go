@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// fakeRuntime is an in-memory Runtime used to test reconcile and other
+// Runtime-driven logic without a real Docker/Podman daemon.
+type fakeRuntime struct {
+	containers map[string]ContainerInfo
+	specs      map[string]ContainerSpec
+	started    map[string]bool
+	subnets    map[string]*net.IPNet
+	nextID     int
+}
+
+func newFakeRuntime() *fakeRuntime {
+	return &fakeRuntime{
+		containers: make(map[string]ContainerInfo),
+		specs:      make(map[string]ContainerSpec),
+		started:    make(map[string]bool),
+		subnets:    make(map[string]*net.IPNet),
+	}
+}
+
+func (f *fakeRuntime) CreateContainer(ctx context.Context, spec ContainerSpec) (string, error) {
+	f.nextID++
+	id := fmt.Sprintf("fake-%d", f.nextID)
+	f.containers[id] = ContainerInfo{ID: id, Name: spec.Name, Labels: spec.Labels}
+	f.specs[id] = spec
+	return id, nil
+}
+
+func (f *fakeRuntime) StartContainer(ctx context.Context, id string) error {
+	if _, ok := f.containers[id]; !ok {
+		return fmt.Errorf("no such container %q", id)
+	}
+	f.started[id] = true
+	return nil
+}
+
+func (f *fakeRuntime) RemoveContainer(ctx context.Context, id string) error {
+	if _, ok := f.containers[id]; !ok {
+		return fmt.Errorf("no such container %q", id)
+	}
+	delete(f.containers, id)
+	delete(f.specs, id)
+	delete(f.started, id)
+	return nil
+}
+
+func (f *fakeRuntime) ListContainers(ctx context.Context, filter map[string]string) ([]ContainerInfo, error) {
+	var ret []ContainerInfo
+	for _, c := range f.containers {
+		match := true
+		for k, v := range filter {
+			if c.Labels[k] != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			ret = append(ret, c)
+		}
+	}
+	return ret, nil
+}
+
+func (f *fakeRuntime) InspectNetworkSubnet(ctx context.Context, name string) (*net.IPNet, error) {
+	subnet, ok := f.subnets[name]
+	if !ok {
+		return nil, fmt.Errorf("no such network %q", name)
+	}
+	return subnet, nil
+}
+
+func (f *fakeRuntime) StreamLogs(ctx context.Context, id string, stdout, stderr io.Writer) error {
+	return nil
+}
+
+func (f *fakeRuntime) Health(ctx context.Context, id string) (string, error) {
+	return "", nil
+}
+
+func (f *fakeRuntime) Wait(ctx context.Context, id string) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeRuntime) StopContainer(ctx context.Context, id string, timeout time.Duration) error {
+	return nil
+}
+
+func (f *fakeRuntime) InspectImageLabels(ctx context.Context, image string) (map[string]string, error) {
+	return nil, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import "strings"
+
+// SELinuxRelabel controls whether and how btsyncw asks Docker to
+// relabel a bind mount for SELinux, mirroring the ":z"/":Z" volume
+// flags.
+type SELinuxRelabel string
+
+const (
+	SELinuxRelabelNone    SELinuxRelabel = ""
+	SELinuxRelabelShared  SELinuxRelabel = "shared"
+	SELinuxRelabelPrivate SELinuxRelabel = "private"
+)
+
+// MountPropagation is the bind propagation mode, mirroring the
+// "rprivate"/"rshared"/"rslave" mount(8) flags.
+type MountPropagation string
+
+const (
+	PropagationRPrivate MountPropagation = "rprivate"
+	PropagationRShared  MountPropagation = "rshared"
+	PropagationRSlave   MountPropagation = "rslave"
+)
+
+// FolderSpec describes one folder to bind-mount into the Sync
+// container.
+type FolderSpec struct {
+	Source string
+	// Target defaults to "/mnt/folders/<basename of Source>" if empty.
+	Target         string
+	ReadOnly       bool
+	SELinuxRelabel SELinuxRelabel
+	Propagation    MountPropagation
+	Consistency    string
+}
+
+// defaultTarget derives the in-container mount point for a folder that
+// didn't specify one explicitly.
+func (f *FolderSpec) defaultTarget() string {
+	if f.Target != "" {
+		return f.Target
+	}
+	parts := strings.Split(strings.TrimRight(f.Source, "/"), "/")
+	return "/mnt/folders/" + parts[len(parts)-1]
+}
+
+// Bind renders a FolderSpec as a Docker legacy bind-mount string
+// ("source:target:options"). We go through this format rather than the
+// newer Mounts API because it's the only one that carries the SELinux
+// relabeling mode ("z"/"Z"), exactly as moby's own volume flag parsing
+// does for `-v`.
+func (f *FolderSpec) Bind() string {
+	opts := make([]string, 0, 3)
+	if f.ReadOnly {
+		opts = append(opts, "ro")
+	}
+	switch f.SELinuxRelabel {
+	case SELinuxRelabelShared:
+		opts = append(opts, "z")
+	case SELinuxRelabelPrivate:
+		opts = append(opts, "Z")
+	}
+	if f.Propagation != "" {
+		opts = append(opts, string(f.Propagation))
+	}
+	if f.Consistency != "" {
+		opts = append(opts, f.Consistency)
+	}
+
+	bind := f.Source + ":" + f.defaultTarget()
+	if len(opts) > 0 {
+		bind += ":" + strings.Join(opts, ",")
+	}
+	return bind
+}
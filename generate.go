@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// SdNotifyLabel is the OCI label Podman itself looks for to decide
+// whether a generated unit should use `Type=notify`. We reuse the same
+// convention rather than inventing our own.
+const SdNotifyLabel = "io.containers.sdnotify"
+
+// generateMain implements `btsyncw generate systemd`.
+func generateMain(args []string) {
+	if len(args) < 1 || args[0] != "systemd" {
+		fmt.Println("Usage: btsyncw generate systemd [--user|--system] [--new] [--files] <config>")
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("generate systemd", flag.ExitOnError)
+	userUnit := fs.Bool("user", false, "Generate a user unit (~/.config/systemd/user/) instead of a system one")
+	systemUnit := fs.Bool("system", false, "Generate a system unit (/etc/systemd/system/); this is the default")
+	newContainer := fs.Bool("new", false, "Generate a unit that creates a fresh container on every start, instead of starting the one reconcile already created")
+	writeFiles := fs.Bool("files", false, "Write unit files instead of printing them to stdout")
+	fs.Parse(args[1:])
+
+	if fs.NArg() != 1 || (*userUnit && *systemUnit) {
+		fmt.Println("Usage: btsyncw generate systemd [--user|--system] [--new] [--files] <config>")
+		os.Exit(1)
+	}
+
+	c, err := loadConfig(fs.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if err := validateRuntimeKind(c.Runtime); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	rt, err := NewRuntime(c.Runtime)
+	if err != nil {
+		fmt.Printf("Failed to set up runtime: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := validateConfig(ctx, rt, c); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	binary := string(c.Runtime)
+	if binary == "" {
+		binary = string(RuntimeDocker)
+	}
+
+	dir, installTarget := systemUnitTarget()
+	if *userUnit {
+		dir, installTarget = userUnitTarget()
+	}
+
+	for idx := range c.Instances {
+		i := &c.Instances[idx]
+
+		hash, err := configHash(i)
+		if err != nil {
+			fmt.Printf("Failed to hash instance %q: %v\n", i.Name, err)
+			os.Exit(1)
+		}
+		spec := buildContainerSpec(i, hash)
+
+		unit := generateUnit(ctx, rt, binary, spec, *newContainer, installTarget)
+
+		if !*writeFiles {
+			fmt.Println(unit)
+			continue
+		}
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fmt.Printf("Failed to create %s: %v\n", dir, err)
+			os.Exit(1)
+		}
+		path := filepath.Join(dir, "btsyncw-"+i.Name+".service")
+		if err := os.WriteFile(path, []byte(unit), 0644); err != nil {
+			fmt.Printf("Failed to write %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %s\n", path)
+	}
+}
+
+// systemUnitTarget returns the unit directory and [Install] target for
+// system-level units.
+func systemUnitTarget() (dir, installTarget string) {
+	return "/etc/systemd/system", "multi-user.target"
+}
+
+// userUnitTarget returns the unit directory and [Install] target for
+// per-user units.
+func userUnitTarget() (dir, installTarget string) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = os.Getenv("HOME")
+	}
+	return filepath.Join(home, ".config", "systemd", "user"), "default.target"
+}
+
+// generateUnit renders the systemd unit for a single instance's
+// container, modeled on `podman generate systemd`.
+func generateUnit(ctx context.Context, rt Runtime, binary string, spec ContainerSpec, newContainer bool, installTarget string) string {
+	unitType := "simple"
+	if labels, err := rt.InspectImageLabels(ctx, spec.Image); err == nil {
+		if v := labels[SdNotifyLabel]; v != "" && v != "ignore" {
+			unitType = "notify"
+		}
+	}
+
+	var execStartPre, execStart, execStop, execStopPost string
+	if newContainer {
+		// A fresh container is created on every start, so any leftover
+		// one from a previous run has to go first.
+		execStartPre = fmt.Sprintf("-%s rm -f %s", binary, spec.Name)
+		execStart = shellJoin(dockerRunArgs(spec, binary))
+		execStop = fmt.Sprintf("%s stop %s", binary, spec.Name)
+		execStopPost = fmt.Sprintf("-%s rm -f %s", binary, spec.Name)
+	} else {
+		// Attach to the container reconcile already created instead of
+		// creating a new one on every start, so there's nothing to
+		// remove beforehand.
+		execStart = fmt.Sprintf("%s start -a %s", binary, spec.Name)
+		execStop = fmt.Sprintf("%s stop %s", binary, spec.Name)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by btsyncw generate systemd for instance %q. Do not edit manually.\n", spec.Name)
+	fmt.Fprintf(&b, "[Unit]\n")
+	fmt.Fprintf(&b, "Description=Resilio Sync container %q managed by btsyncw\n", spec.Name)
+	fmt.Fprintf(&b, "After=network-online.target %s.service\n", binary)
+	fmt.Fprintf(&b, "Wants=network-online.target\n\n")
+	fmt.Fprintf(&b, "[Service]\n")
+	fmt.Fprintf(&b, "Type=%s\n", unitType)
+	if execStartPre != "" {
+		fmt.Fprintf(&b, "ExecStartPre=%s\n", execStartPre)
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", execStart)
+	fmt.Fprintf(&b, "ExecStop=%s\n", execStop)
+	if execStopPost != "" {
+		fmt.Fprintf(&b, "ExecStopPost=%s\n", execStopPost)
+	}
+	fmt.Fprintf(&b, "Restart=on-failure\n\n")
+	fmt.Fprintf(&b, "[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=%s\n", installTarget)
+
+	return b.String()
+}
+
+// dockerRunArgs renders spec as the argv of an equivalent
+// `docker run`/`podman run` invocation.
+func dockerRunArgs(spec ContainerSpec, binary string) []string {
+	args := []string{binary, "run", "--name", spec.Name}
+
+	for _, bind := range spec.Binds {
+		args = append(args, "-v", bind)
+	}
+	for _, env := range spec.Env {
+		args = append(args, "-e", env)
+	}
+	if spec.Network != "" {
+		args = append(args, "--network", spec.Network)
+		if spec.Ip != "" {
+			args = append(args, "--ip", spec.Ip)
+		}
+	}
+	labelKeys := make([]string, 0, len(spec.Labels))
+	for k := range spec.Labels {
+		labelKeys = append(labelKeys, k)
+	}
+	sort.Strings(labelKeys)
+	for _, k := range labelKeys {
+		args = append(args, "--label", k+"="+spec.Labels[k])
+	}
+	if spec.RestartPolicy != "" {
+		args = append(args, "--restart", spec.RestartPolicy)
+	}
+
+	return append(args, spec.Image)
+}
+
+// shellJoin renders argv as a POSIX shell command line, quoting
+// arguments that contain characters a shell would otherwise interpret.
+func shellJoin(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, a := range argv {
+		if a != "" && !strings.ContainsAny(a, " \t\n\"'$`\\|&;<>()*?[]#~!{}") {
+			quoted[i] = a
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
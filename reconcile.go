@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// LabelManaged marks a container as being under btsyncw's control,
+	// similar to how docker-compose tags containers it manages.
+	LabelManaged = "btsyncw.managed"
+	// LabelInstance records which Instance (by Name) a container
+	// belongs to.
+	LabelInstance = "btsyncw.instance"
+	// LabelConfigHash records a hash of the Instance that produced a
+	// container, so we can tell whether it's still up to date.
+	LabelConfigHash = "btsyncw.config-hash"
+)
+
+// configHash returns a short, stable hash of an Instance's desired
+// state, so reconcile can detect when a running container no longer
+// matches the config.
+func configHash(i *Instance) (string, error) {
+	buf, err := json.Marshal(i)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])[:12], nil
+}
+
+// buildBinds turns an Instance's folders and storage path into bind
+// mount strings for the container.
+func buildBinds(i *Instance) []string {
+	binds := make([]string, 0, len(i.Folders)+1)
+	for idx := range i.Folders {
+		binds = append(binds, i.Folders[idx].Bind())
+	}
+	// We append the storage path to make our life easier
+	storage := FolderSpec{Source: i.Storage, Target: "/mnt/config"}
+	binds = append(binds, storage.Bind())
+	return binds
+}
+
+// buildContainerSpec turns an Instance into the ContainerSpec a
+// Runtime needs to create its container.
+func buildContainerSpec(i *Instance, hash string) ContainerSpec {
+	return ContainerSpec{
+		Name:          i.Name,
+		Image:         DockerImage + ":" + DockerImageTag,
+		Env:           buildEnvVars(i),
+		Binds:         buildBinds(i),
+		Network:       i.Network,
+		Ip:            i.Ip,
+		RestartPolicy: i.RestartPolicy,
+		Labels: map[string]string{
+			LabelManaged:    "true",
+			LabelInstance:   i.Name,
+			LabelConfigHash: hash,
+		},
+	}
+}
+
+// reconcile brings the Runtime's managed containers in line with
+// instances: creating missing ones, recreating ones whose config
+// changed, and removing ones that are no longer in the config. If
+// dryRun is true, it only prints what it would do.
+func reconcile(ctx context.Context, rt Runtime, instances []Instance, dryRun bool) error {
+	existing, err := rt.ListContainers(ctx, map[string]string{LabelManaged: "true"})
+	if err != nil {
+		return fmt.Errorf("failed to list managed containers: %w", err)
+	}
+
+	existingByName := make(map[string]ContainerInfo, len(existing))
+	for _, c := range existing {
+		existingByName[c.Labels[LabelInstance]] = c
+	}
+
+	desiredNames := make(map[string]bool, len(instances))
+	for idx := range instances {
+		i := &instances[idx]
+		desiredNames[i.Name] = true
+
+		hash, err := configHash(i)
+		if err != nil {
+			return fmt.Errorf("failed to hash instance %q: %w", i.Name, err)
+		}
+
+		cur, ok := existingByName[i.Name]
+		if ok && cur.Labels[LabelConfigHash] == hash {
+			fmt.Printf("%q is up to date\n", i.Name)
+			continue
+		}
+
+		if ok {
+			if dryRun {
+				fmt.Printf("would recreate %q (config changed)\n", i.Name)
+				continue
+			}
+			fmt.Printf("recreating %q (config changed)\n", i.Name)
+			if err := rt.RemoveContainer(ctx, cur.ID); err != nil {
+				return fmt.Errorf("failed to remove stale container for %q: %w", i.Name, err)
+			}
+		} else {
+			if dryRun {
+				fmt.Printf("would create %q\n", i.Name)
+				continue
+			}
+			fmt.Printf("creating %q\n", i.Name)
+		}
+
+		spec := buildContainerSpec(i, hash)
+		id, err := rt.CreateContainer(ctx, spec)
+		if err != nil {
+			return fmt.Errorf("failed to create container for %q: %w", i.Name, err)
+		}
+		if err := rt.StartContainer(ctx, id); err != nil {
+			return fmt.Errorf("failed to start container for %q: %w", i.Name, err)
+		}
+	}
+
+	for name, c := range existingByName {
+		if desiredNames[name] {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("would remove %q (no longer in config)\n", name)
+			continue
+		}
+		fmt.Printf("removing %q (no longer in config)\n", name)
+		if err := rt.RemoveContainer(ctx, c.ID); err != nil {
+			return fmt.Errorf("failed to remove %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestReconcileCreatesMissingInstances(t *testing.T) {
+	rt := newFakeRuntime()
+	instances := []Instance{
+		{Name: "alice", Storage: "/data/alice", Uid: 1000, Gid: 1000},
+	}
+
+	if err := reconcile(context.Background(), rt, instances, false); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	containers, err := rt.ListContainers(context.Background(), map[string]string{LabelInstance: "alice"})
+	if err != nil {
+		t.Fatalf("ListContainers: %v", err)
+	}
+	if len(containers) != 1 {
+		t.Fatalf("got %d containers, want 1", len(containers))
+	}
+	if !rt.started[containers[0].ID] {
+		t.Error("container was created but not started")
+	}
+}
+
+func TestReconcileRecreatesOnConfigChange(t *testing.T) {
+	rt := newFakeRuntime()
+	instance := Instance{Name: "alice", Storage: "/data/alice", Uid: 1000, Gid: 1000}
+
+	if err := reconcile(context.Background(), rt, []Instance{instance}, false); err != nil {
+		t.Fatalf("initial reconcile: %v", err)
+	}
+	before, err := rt.ListContainers(context.Background(), map[string]string{LabelInstance: "alice"})
+	if err != nil || len(before) != 1 {
+		t.Fatalf("ListContainers after initial reconcile: %v, %#v", err, before)
+	}
+	firstID := before[0].ID
+
+	instance.Storage = "/data/alice-2"
+	if err := reconcile(context.Background(), rt, []Instance{instance}, false); err != nil {
+		t.Fatalf("second reconcile: %v", err)
+	}
+
+	after, err := rt.ListContainers(context.Background(), map[string]string{LabelInstance: "alice"})
+	if err != nil {
+		t.Fatalf("ListContainers after second reconcile: %v", err)
+	}
+	if len(after) != 1 {
+		t.Fatalf("got %d containers, want 1", len(after))
+	}
+	if after[0].ID == firstID {
+		t.Error("expected the container to be recreated with a new ID after the config changed")
+	}
+}
+
+func TestReconcileIsNoopWhenUnchanged(t *testing.T) {
+	rt := newFakeRuntime()
+	instance := Instance{Name: "alice", Storage: "/data/alice", Uid: 1000, Gid: 1000}
+
+	if err := reconcile(context.Background(), rt, []Instance{instance}, false); err != nil {
+		t.Fatalf("initial reconcile: %v", err)
+	}
+	before, _ := rt.ListContainers(context.Background(), map[string]string{LabelInstance: "alice"})
+
+	if err := reconcile(context.Background(), rt, []Instance{instance}, false); err != nil {
+		t.Fatalf("second reconcile: %v", err)
+	}
+	after, _ := rt.ListContainers(context.Background(), map[string]string{LabelInstance: "alice"})
+
+	if len(after) != 1 || after[0].ID != before[0].ID {
+		t.Fatalf("expected the unchanged instance's container to be left alone, before=%#v after=%#v", before, after)
+	}
+}
+
+func TestReconcileRemovesUndesiredInstances(t *testing.T) {
+	rt := newFakeRuntime()
+	instances := []Instance{
+		{Name: "alice", Storage: "/data/alice", Uid: 1000, Gid: 1000},
+		{Name: "bob", Storage: "/data/bob", Uid: 1000, Gid: 1000},
+	}
+	if err := reconcile(context.Background(), rt, instances, false); err != nil {
+		t.Fatalf("initial reconcile: %v", err)
+	}
+
+	if err := reconcile(context.Background(), rt, instances[:1], false); err != nil {
+		t.Fatalf("second reconcile: %v", err)
+	}
+
+	containers, err := rt.ListContainers(context.Background(), map[string]string{LabelManaged: "true"})
+	if err != nil {
+		t.Fatalf("ListContainers: %v", err)
+	}
+	if len(containers) != 1 || containers[0].Labels[LabelInstance] != "alice" {
+		t.Fatalf("expected only 'alice' to remain managed, got %#v", containers)
+	}
+}
+
+func TestReconcileDryRunMakesNoChanges(t *testing.T) {
+	rt := newFakeRuntime()
+	instances := []Instance{{Name: "alice", Storage: "/data/alice", Uid: 1000, Gid: 1000}}
+
+	if err := reconcile(context.Background(), rt, instances, true); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	containers, err := rt.ListContainers(context.Background(), map[string]string{LabelInstance: "alice"})
+	if err != nil {
+		t.Fatalf("ListContainers: %v", err)
+	}
+	if len(containers) != 0 {
+		t.Fatalf("dry-run created %d containers, want 0", len(containers))
+	}
+}
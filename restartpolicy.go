@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RestartPolicy is a parsed Instance.RestartPolicy string, following
+// Docker's own `--restart` flag syntax: "no", "always",
+// "unless-stopped", "on-failure" or "on-failure:N".
+type RestartPolicy struct {
+	Name       string
+	MaxRetries int
+}
+
+// ParseRestartPolicy parses a restart policy string. An empty string
+// means "no".
+func ParseRestartPolicy(s string) (RestartPolicy, error) {
+	if s == "" {
+		s = "no"
+	}
+
+	name, countStr, hasCount := strings.Cut(s, ":")
+	switch name {
+	case "no", "always", "unless-stopped", "on-failure":
+	default:
+		return RestartPolicy{}, fmt.Errorf("unknown restart policy %q", s)
+	}
+
+	rp := RestartPolicy{Name: name}
+	if hasCount {
+		if name != "on-failure" {
+			return RestartPolicy{}, fmt.Errorf("restart policy %q does not take a retry count", name)
+		}
+		n, err := strconv.Atoi(countStr)
+		if err != nil {
+			return RestartPolicy{}, fmt.Errorf("invalid retry count in restart policy %q: %w", s, err)
+		}
+		rp.MaxRetries = n
+	}
+	return rp, nil
+}
+
+// ShouldRestart reports whether a container that just exited with
+// exitCode should be restarted again client-side, where attempt is the
+// number of the restart being considered (1 for the first one). This mirrors what the daemon would
+// do on its own, for the case where the container was recreated with
+// AutoRemove and the daemon-side policy no longer applies.
+func (p RestartPolicy) ShouldRestart(exitCode int64, attempt int) bool {
+	switch p.Name {
+	case "always", "unless-stopped":
+		return true
+	case "on-failure":
+		if exitCode == 0 {
+			return false
+		}
+		return p.MaxRetries == 0 || attempt <= p.MaxRetries
+	default:
+		return false
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// RuntimeKind selects which container engine backend btsyncw talks to.
+type RuntimeKind string
+
+const (
+	RuntimeDocker RuntimeKind = "docker"
+	RuntimePodman RuntimeKind = "podman"
+)
+
+// ContainerSpec describes the container we want a Runtime to create,
+// independent of which backend ends up creating it.
+type ContainerSpec struct {
+	Name  string
+	Image string
+	Env   []string
+	// Binds are Docker legacy bind-mount strings ("source:target:options"),
+	// used instead of the newer Mounts API so SELinux relabeling options
+	// ("z"/"Z") can be expressed; see FolderSpec.Bind.
+	Binds         []string
+	Network       string
+	Ip            string
+	Labels        map[string]string
+	RestartPolicy string
+}
+
+// ContainerInfo is what we need to know about an already-existing
+// container when reconciling the desired state against it.
+type ContainerInfo struct {
+	ID     string
+	Name   string
+	Labels map[string]string
+}
+
+// Runtime abstracts over the container engine we talk to, so that
+// btsyncw can drive either a Docker daemon or a rootless Podman host
+// through the same calling code.
+type Runtime interface {
+	// CreateContainer creates (but does not start) a container matching
+	// spec and returns its ID.
+	CreateContainer(ctx context.Context, spec ContainerSpec) (string, error)
+	// StartContainer starts the container with the given ID.
+	StartContainer(ctx context.Context, id string) error
+	// RemoveContainer removes the container with the given ID.
+	RemoveContainer(ctx context.Context, id string) error
+	// ListContainers returns the containers whose labels match every
+	// key/value pair in filter.
+	ListContainers(ctx context.Context, filter map[string]string) ([]ContainerInfo, error)
+	// InspectNetworkSubnet returns the subnet configured for the named
+	// network, so config validation can check a static IP actually
+	// falls within it.
+	InspectNetworkSubnet(ctx context.Context, name string) (*net.IPNet, error)
+	// StreamLogs copies the container's stdout/stderr to the given
+	// writers until it stops producing output or ctx is cancelled.
+	StreamLogs(ctx context.Context, id string, stdout, stderr io.Writer) error
+	// Health reports the container's health status ("healthy",
+	// "unhealthy", "starting", or "" if it defines no healthcheck).
+	Health(ctx context.Context, id string) (string, error)
+	// Wait blocks until the container exits and returns its exit code.
+	Wait(ctx context.Context, id string) (int64, error)
+	// StopContainer stops the container, giving it timeout to exit
+	// gracefully before it's killed.
+	StopContainer(ctx context.Context, id string, timeout time.Duration) error
+	// InspectImageLabels returns the OCI labels baked into image,
+	// without requiring it to already be running as a container.
+	InspectImageLabels(ctx context.Context, image string) (map[string]string, error)
+}
+
+// NewRuntime constructs the Runtime backend selected by kind. An empty
+// kind defaults to Docker, matching the behaviour before Runtime
+// existed.
+func NewRuntime(kind RuntimeKind) (Runtime, error) {
+	switch kind {
+	case "", RuntimeDocker:
+		return newDockerRuntime()
+	case RuntimePodman:
+		return newPodmanRuntime()
+	default:
+		return nil, fmt.Errorf("unknown runtime %q, expected %q or %q", kind, RuntimeDocker, RuntimePodman)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"golang.org/x/net/context"
+)
+
+// dockerRuntime talks to a Docker daemon over the client's default
+// connection (respecting DOCKER_HOST and friends).
+type dockerRuntime struct {
+	cli *client.Client
+}
+
+func newDockerRuntime() (Runtime, error) {
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return nil, err
+	}
+	return &dockerRuntime{cli: cli}, nil
+}
+
+func (r *dockerRuntime) CreateContainer(ctx context.Context, spec ContainerSpec) (string, error) {
+	containerConfig, hostConfig, netConfig := buildDockerConfigs(spec)
+
+	resp, err := r.cli.ContainerCreate(ctx, &containerConfig, &hostConfig, &netConfig, spec.Name)
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+func (r *dockerRuntime) StartContainer(ctx context.Context, id string) error {
+	return r.cli.ContainerStart(ctx, id, types.ContainerStartOptions{})
+}
+
+func (r *dockerRuntime) RemoveContainer(ctx context.Context, id string) error {
+	return r.cli.ContainerRemove(ctx, id, types.ContainerRemoveOptions{Force: true})
+}
+
+func (r *dockerRuntime) ListContainers(ctx context.Context, filter map[string]string) ([]ContainerInfo, error) {
+	args := filters.NewArgs()
+	for k, v := range filter {
+		args.Add("label", k+"="+v)
+	}
+
+	containers, err := r.cli.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: args})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]ContainerInfo, 0, len(containers))
+	for _, c := range containers {
+		name := ""
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		ret = append(ret, ContainerInfo{ID: c.ID, Name: name, Labels: c.Labels})
+	}
+	return ret, nil
+}
+
+func (r *dockerRuntime) InspectNetworkSubnet(ctx context.Context, name string) (*net.IPNet, error) {
+	nw, err := r.cli.NetworkInspect(ctx, name, types.NetworkInspectOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cfg := range nw.IPAM.Config {
+		if cfg.Subnet == "" {
+			continue
+		}
+		_, subnet, err := net.ParseCIDR(cfg.Subnet)
+		if err != nil {
+			continue
+		}
+		return subnet, nil
+	}
+
+	return nil, fmt.Errorf("network %q has no configured subnet", name)
+}
+
+func (r *dockerRuntime) StreamLogs(ctx context.Context, id string, stdout, stderr io.Writer) error {
+	rc, err := r.cli.ContainerLogs(ctx, id, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+	})
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	_, err = stdcopy.StdCopy(stdout, stderr, rc)
+	return err
+}
+
+func (r *dockerRuntime) Health(ctx context.Context, id string) (string, error) {
+	info, err := r.cli.ContainerInspect(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if info.State == nil || info.State.Health == nil {
+		return "", nil
+	}
+	return info.State.Health.Status, nil
+}
+
+func (r *dockerRuntime) Wait(ctx context.Context, id string) (int64, error) {
+	statusCh, errCh := r.cli.ContainerWait(ctx, id, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return 0, err
+	case status := <-statusCh:
+		return status.StatusCode, nil
+	}
+}
+
+func (r *dockerRuntime) StopContainer(ctx context.Context, id string, timeout time.Duration) error {
+	return r.cli.ContainerStop(ctx, id, &timeout)
+}
+
+func (r *dockerRuntime) InspectImageLabels(ctx context.Context, image string) (map[string]string, error) {
+	info, _, err := r.cli.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	if info.Config == nil {
+		return nil, nil
+	}
+	return info.Config.Labels, nil
+}
+
+// buildDockerConfigs translates a backend-agnostic ContainerSpec into
+// the container/network config structs the Docker API (and, since it
+// speaks the same wire protocol, the Podman compat API) expects.
+func buildDockerConfigs(spec ContainerSpec) (container.Config, container.HostConfig, network.NetworkingConfig) {
+	containerConfig := container.Config{
+		Image:  spec.Image,
+		Env:    spec.Env,
+		Labels: spec.Labels,
+	}
+
+	// AutoRemove is deliberately left off: containers are labelled and
+	// tracked by reconcile instead, so they survive a daemon restart
+	// rather than vanishing the moment they stop.
+	hostConfig := container.HostConfig{
+		Binds: spec.Binds,
+	}
+
+	if rp, err := ParseRestartPolicy(spec.RestartPolicy); err == nil {
+		hostConfig.RestartPolicy = container.RestartPolicy{
+			Name:              rp.Name,
+			MaximumRetryCount: rp.MaxRetries,
+		}
+	}
+
+	netConfig := network.NetworkingConfig{}
+
+	// Apply the NetworkMode only if we have a Network specified
+	if spec.Network != "" {
+		hostConfig.NetworkMode = container.NetworkMode(spec.Network)
+
+		// Apply the IP only if we got both an IP and a Network
+		if spec.Ip != "" {
+			netConfig.EndpointsConfig = map[string]*network.EndpointSettings{
+				spec.Network: {IPAddress: spec.Ip},
+			}
+		}
+	}
+
+	return containerConfig, hostConfig, netConfig
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/docker/docker/client"
+)
+
+// podmanRuntime talks to Podman's Docker-compatible REST API over a
+// Unix socket instead of a Docker daemon, so users on rootless Podman
+// hosts don't need dockerd running at all. Since that compat endpoint
+// speaks the same wire protocol as the Docker daemon, we get to reuse
+// dockerRuntime entirely and only swap out how the client connects.
+type podmanRuntime struct {
+	*dockerRuntime
+}
+
+// defaultPodmanSocket returns the usual location of the libpod compat
+// API socket on a rootless Podman host, as exposed by pkg/api/server:
+// $XDG_RUNTIME_DIR/podman/podman.sock, which defaults to
+// /run/user/<uid> for the current user rather than a hardcoded uid.
+func defaultPodmanSocket() string {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = fmt.Sprintf("/run/user/%d", os.Getuid())
+	}
+	return "unix://" + runtimeDir + "/podman/podman.sock"
+}
+
+func newPodmanRuntime() (Runtime, error) {
+	socket := os.Getenv("PODMAN_SOCKET")
+	if socket == "" {
+		socket = defaultPodmanSocket()
+	}
+
+	cli, err := client.NewClientWithOpts(client.WithHost(socket), client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+	return &podmanRuntime{&dockerRuntime{cli: cli}}, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestFakeRuntimeCreateContainerThenStart(t *testing.T) {
+	rt := newFakeRuntime()
+	spec := ContainerSpec{
+		Name:   "Sync",
+		Image:  "sync:slim",
+		Labels: map[string]string{LabelManaged: "true", LabelInstance: "Sync"},
+	}
+
+	id, err := rt.CreateContainer(context.Background(), spec)
+	if err != nil {
+		t.Fatalf("CreateContainer: %v", err)
+	}
+	if id == "" {
+		t.Fatal("CreateContainer returned an empty ID")
+	}
+
+	if err := rt.StartContainer(context.Background(), id); err != nil {
+		t.Fatalf("StartContainer: %v", err)
+	}
+	if !rt.started[id] {
+		t.Error("container was not marked as started")
+	}
+
+	containers, err := rt.ListContainers(context.Background(), map[string]string{LabelInstance: "Sync"})
+	if err != nil {
+		t.Fatalf("ListContainers: %v", err)
+	}
+	if len(containers) != 1 || containers[0].ID != id {
+		t.Fatalf("ListContainers = %#v, want a single entry with ID %q", containers, id)
+	}
+}
+
+func TestFakeRuntimeStartContainerRequiresExistingContainer(t *testing.T) {
+	rt := newFakeRuntime()
+	if err := rt.StartContainer(context.Background(), "does-not-exist"); err == nil {
+		t.Error("expected an error starting a container that was never created")
+	}
+}
+
+func TestBuildDockerConfigsAppliesNetworkAndIP(t *testing.T) {
+	spec := ContainerSpec{
+		Name:    "Sync",
+		Image:   "sync:slim",
+		Binds:   []string{"/data:/mnt/config"},
+		Network: "syncnet",
+		Ip:      "10.0.0.5",
+		Labels:  map[string]string{LabelInstance: "Sync"},
+	}
+
+	containerConfig, hostConfig, netConfig := buildDockerConfigs(spec)
+
+	if containerConfig.Image != spec.Image {
+		t.Errorf("Image = %q, want %q", containerConfig.Image, spec.Image)
+	}
+	if string(hostConfig.NetworkMode) != spec.Network {
+		t.Errorf("NetworkMode = %q, want %q", hostConfig.NetworkMode, spec.Network)
+	}
+
+	endpoint, ok := netConfig.EndpointsConfig[spec.Network]
+	if !ok {
+		t.Fatalf("no endpoint config for network %q", spec.Network)
+	}
+	if endpoint.IPAddress != spec.Ip {
+		t.Errorf("IPAddress = %q, want %q", endpoint.IPAddress, spec.Ip)
+	}
+}
+
+func TestBuildDockerConfigsSkipsNetworkingWithoutNetwork(t *testing.T) {
+	spec := ContainerSpec{Name: "Sync", Image: "sync:slim"}
+
+	_, hostConfig, netConfig := buildDockerConfigs(spec)
+
+	if hostConfig.NetworkMode != "" {
+		t.Errorf("NetworkMode = %q, want empty", hostConfig.NetworkMode)
+	}
+	if len(netConfig.EndpointsConfig) != 0 {
+		t.Errorf("expected no endpoint configs, got %d", len(netConfig.EndpointsConfig))
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// LogDriver selects how a supervised container's logs are rendered on
+// stdout.
+type LogDriver string
+
+const (
+	LogDriverPlain    LogDriver = "plain"
+	LogDriverJSONFile LogDriver = "json-file"
+)
+
+// StopTimeout is how long a supervised container gets to exit
+// gracefully on SIGINT/SIGTERM before we give up waiting on it.
+const StopTimeout = 10 * time.Second
+
+// jsonFileWriter renders each line written to it as a Docker
+// json-file-style log entry ({"log":..., "stream":..., "time":...}).
+type jsonFileWriter struct {
+	stream string
+	buf    []byte
+}
+
+func (w *jsonFileWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		line := w.buf[:idx+1]
+		w.buf = w.buf[idx+1:]
+
+		entry, err := json.Marshal(struct {
+			Log    string `json:"log"`
+			Stream string `json:"stream"`
+			Time   string `json:"time"`
+		}{
+			Log:    string(line),
+			Stream: w.stream,
+			Time:   time.Now().UTC().Format(time.RFC3339Nano),
+		})
+		if err != nil {
+			return 0, err
+		}
+		fmt.Println(string(entry))
+	}
+	return len(p), nil
+}
+
+// logWriters returns the stdout/stderr writers a container's logs
+// should be copied to for the given driver.
+func logWriters(instanceName string, driver LogDriver) (stdout, stderr io.Writer) {
+	switch driver {
+	case LogDriverJSONFile:
+		return &jsonFileWriter{stream: "stdout"}, &jsonFileWriter{stream: "stderr"}
+	default:
+		prefix := "[" + instanceName + "] "
+		return &linePrefixWriter{prefix: prefix, out: os.Stdout}, &linePrefixWriter{prefix: prefix, out: os.Stdout}
+	}
+}
+
+// linePrefixWriter prefixes every line with the instance name, so logs
+// from several supervised instances stay distinguishable on stdout.
+type linePrefixWriter struct {
+	prefix string
+	out    io.Writer
+	buf    []byte
+}
+
+func (w *linePrefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		line := w.buf[:idx]
+		w.buf = w.buf[idx+1:]
+		fmt.Fprintf(w.out, "%s%s\n", w.prefix, line)
+	}
+	return len(p), nil
+}
+
+// supervise keeps btsyncw attached to the reconciled containers: it
+// streams their logs, polls health, and restarts them according to
+// each Instance's RestartPolicy until it's asked to stop or a
+// container exits for good. It returns the exit code btsyncw itself
+// should use.
+func supervise(ctx context.Context, rt Runtime, instances []Instance, driver LogDriver) (int, error) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Track which signal, if any, triggered the shutdown, so it can be
+	// reflected in our own exit code instead of being conflated with a
+	// container that happened to exit cleanly on its own.
+	var receivedSignal os.Signal
+	go func() {
+		select {
+		case sig := <-sigCh:
+			receivedSignal = sig
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	type result struct {
+		code int
+		err  error
+	}
+	results := make(chan result, len(instances))
+	for idx := range instances {
+		i := &instances[idx]
+		go func() {
+			code, err := superviseInstance(ctx, rt, i, driver)
+			results <- result{code, err}
+		}()
+	}
+
+	exitCode := 0
+	var firstErr error
+	for range instances {
+		res := <-results
+		if res.err != nil && firstErr == nil {
+			firstErr = res.err
+		}
+		if res.code != 0 && exitCode == 0 {
+			exitCode = res.code
+		}
+	}
+
+	// The happens-before chain through cancel() closing ctx.Done(),
+	// superviseInstance observing that, and its result reaching us over
+	// the results channel means receivedSignal is safe to read here
+	// without further synchronization.
+	if receivedSignal != nil {
+		return signalExitCode(receivedSignal), firstErr
+	}
+	return exitCode, firstErr
+}
+
+// signalExitCode renders sig as the conventional shell "terminated by
+// signal N" exit code (128+N), so a Ctrl-C shutdown is distinguishable
+// from a container that exited with status 0 on its own.
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 128
+}
+
+// superviseInstance supervises a single instance's container: find it,
+// stream its logs and health, wait for it to exit, and either restart
+// it per RestartPolicy or report its final exit code.
+func superviseInstance(ctx context.Context, rt Runtime, i *Instance, driver LogDriver) (int, error) {
+	policy, err := ParseRestartPolicy(i.RestartPolicy)
+	if err != nil {
+		return 0, fmt.Errorf("invalid restart policy for %q: %w", i.Name, err)
+	}
+
+	containers, err := rt.ListContainers(ctx, map[string]string{LabelInstance: i.Name})
+	if err != nil || len(containers) == 0 {
+		return 0, fmt.Errorf("no container found for instance %q", i.Name)
+	}
+	id := containers[0].ID
+	go pollHealth(ctx, rt, i.Name, id)
+
+	attempt := 0
+	for {
+		// (Re)attach to the container's log stream: it ends whenever
+		// the container does, so each restart needs its own attempt.
+		stdout, stderr := logWriters(i.Name, driver)
+		go rt.StreamLogs(ctx, id, stdout, stderr)
+
+		code, waitErr := rt.Wait(ctx, id)
+		if ctx.Err() != nil {
+			// We were asked to stop. The exit code here is meaningless
+			// (Wait returns 0 once its context is cancelled); supervise
+			// overrides the process exit code with the signal that
+			// caused this instead.
+			stopCtx, cancel := context.WithTimeout(context.Background(), StopTimeout)
+			defer cancel()
+			if err := rt.StopContainer(stopCtx, id, StopTimeout); err != nil {
+				return 0, fmt.Errorf("failed to stop %q: %w", i.Name, err)
+			}
+			return 0, nil
+		}
+		if waitErr != nil {
+			return 0, fmt.Errorf("failed waiting for %q: %w", i.Name, waitErr)
+		}
+
+		attempt++
+		if !policy.ShouldRestart(code, attempt) {
+			return int(code), nil
+		}
+
+		fmt.Printf("%q exited with code %d, restarting (attempt %d)\n", i.Name, code, attempt)
+		if err := rt.StartContainer(ctx, id); err != nil {
+			return int(code), fmt.Errorf("failed to restart %q: %w", i.Name, err)
+		}
+	}
+}
+
+// pollHealth periodically prints a container's health status until ctx
+// is cancelled.
+func pollHealth(ctx context.Context, rt Runtime, instanceName, id string) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	last := ""
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			status, err := rt.Health(ctx, id)
+			if err != nil || status == "" || status == last {
+				continue
+			}
+			last = status
+			fmt.Printf("%q health: %s\n", instanceName, status)
+		}
+	}
+}